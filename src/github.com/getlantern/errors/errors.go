@@ -31,15 +31,45 @@ Error() method.
 
 It's the caller's responsibility to avoid race condition accessing same error
 instance from multiple goroutines.
+
+An *Error created by New() or Wrap() implements Unwrap(), so it works with
+errors.Is(), errors.As() and errors.Unwrap() from the standard library just
+like the error it wraps. When the wrapped error is itself a chain (e.g. a
+*net.OpError wrapping a *net.DNSError wrapping a syscall.Errno), the full
+chain is walked so that fields from every layer end up on the same *Error.
+
+Every *Error also carries a coarse error_category ("dns", "dial",
+"tls_handshake", "tls_verify", "http_protocol", "context", "filesystem",
+"syscall" or "application") along with timeout/temporary/canceled/
+dns_not_found booleans, all computed once at wrap time and readable via
+IsTimeout(), IsTemporary(), IsCanceled(), IsDNSNotFound() and Category().
+
+Report() sends the Error through whatever pipeline was assembled with
+Initialize and RegisterReporterMiddleware; chain together
+NewDeduplicatingMiddleware, NewSamplingMiddleware, NewBatchingMiddleware and
+NewSeverityFilterMiddleware to control how much of a noisy error (e.g.
+thousands of identical dial failures while offline) actually reaches the
+underlying reporter. Stats() reports reports_received/dropped/deduped for
+the whole pipeline.
+
+*Error implements json.Marshaler with a stable schema (error, error_type,
+error_op, error_category, timeout, severity, stack, and an extras map for
+everything else), and NewFromJSON reconstitutes an Error from that schema so that
+context survives a process boundary (e.g. a helper binary reporting back to
+the main process) instead of collapsing into a plain string. ToOTLP
+produces an OTLP-LogRecord-shaped map for operators who ship straight to an
+OTLP collector.
 */
 package errors
 
 import (
 	"bufio"
+	stdcontext "context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -48,17 +78,331 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unicode"
 
 	"github.com/getlantern/context"
+	"github.com/getlantern/golog"
 )
 
+// defaultMaxStackFrames is how many stack frames attachStack captures when
+// no MaxStackFrames option has been given to Initialize.
+const defaultMaxStackFrames = 32
+
+// ErrorReporter reports Errors to some backend, e.g. a metrics or logging
+// service. It's the interface implemented by the reporter passed to
+// Initialize, as well as by anything built with RegisterReporterMiddleware.
+type ErrorReporter interface {
+	Report(e *Error)
+}
+
+// Option customizes the behavior configured by Initialize.
+type Option func(*config)
+
+// DisableStackCapture turns off stack trace capture for every Error created
+// after this option is applied. Useful on performance-sensitive paths where
+// the cost of runtime.Callers isn't worth paying.
+func DisableStackCapture() Option {
+	return func(c *config) {
+		c.captureStack = false
+	}
+}
+
+// MaxStackFrames caps the number of stack frames attachStack records for
+// each Error. The default is 32.
+func MaxStackFrames(n int) Option {
+	return func(c *config) {
+		c.maxStackFrames = n
+	}
+}
+
+type config struct {
+	appVersion     string
+	reporter       ErrorReporter
+	logging        bool
+	captureStack   bool
+	maxStackFrames int
+}
+
+var cfg = &config{captureStack: true, maxStackFrames: defaultMaxStackFrames}
+
+// Initialize configures the package-wide behavior of Report(): appVersion is
+// kept in the package configuration (it is not attached to the Errors handed
+// to the reporter), reporter receives errors reported via
+// Report() (directly, or through whatever pipeline has been assembled with
+// RegisterReporterMiddleware), and logging controls whether Report() also
+// logs the error via golog. Initialize is meant to be called once at
+// startup, before any Errors are reported.
+func Initialize(appVersion string, reporter ErrorReporter, logging bool, opts ...Option) {
+	c := &config{
+		appVersion:     appVersion,
+		reporter:       reporter,
+		logging:        logging,
+		captureStack:   true,
+		maxStackFrames: defaultMaxStackFrames,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	cfg = c
+	pipeline = cfg.reporter
+}
+
+// Report sends this error to the reporter (and middleware pipeline, if any)
+// configured via Initialize, and if logging was enabled, also logs it via
+// golog using the package the error originated in.
+func (e *Error) Report() {
+	if cfg.logging {
+		pkg, _ := e.data["error_package"].(string)
+		if pkg == "" {
+			pkg = "errors"
+		}
+		golog.LoggerFor(pkg).Error(e)
+	}
+	dispatch(e)
+}
+
+// Report is a convenience function equivalent to errors.Wrap(err).Report().
+// It's a no-op if err is nil.
+func Report(err error) {
+	e := wrapSkipFrames(err, 1)
+	if e == nil {
+		return
+	}
+	e.Report()
+}
+
+// ReporterMiddleware wraps an ErrorReporter to add cross-cutting behavior
+// (deduplication, sampling, batching, filtering) in front of whatever
+// reporter already sits in the pipeline. RegisterReporterMiddleware applies
+// these incrementally as they're registered, so the most recently
+// registered middleware is the first to see a reported Error; the plain
+// single-reporter path from Initialize is just the degenerate case of no
+// middlewares at all.
+type ReporterMiddleware func(next ErrorReporter) ErrorReporter
+
+// reporterFunc adapts a plain func(*Error) to the ErrorReporter interface.
+type reporterFunc func(e *Error)
+
+func (f reporterFunc) Report(e *Error) { f(e) }
+
+// pipeline is the reporter that actually receives dispatched Errors: cfg's
+// configured reporter, wrapped by whatever middlewares have been registered
+// via RegisterReporterMiddleware.
+var pipeline ErrorReporter
+
+// RegisterReporterMiddleware wraps the current pipeline with mw. This only
+// adds mw on top of what's already there rather than reconstructing the
+// whole chain, so stateful middlewares already in the pipeline (e.g. a
+// NewDeduplicatingMiddleware or NewBatchingMiddleware with a background
+// flush goroutine) are left running instead of being torn down and
+// recreated on every call.
+func RegisterReporterMiddleware(mw ReporterMiddleware) {
+	pipeline = mw(pipeline)
+}
+
+var reportStats struct {
+	received int64
+	dropped  int64
+	deduped  int64
+}
+
+// Stats returns a snapshot of the reporter pipeline's metrics:
+// reports_received, reports_dropped and reports_deduped.
+func Stats() map[string]int64 {
+	return map[string]int64{
+		"reports_received": atomic.LoadInt64(&reportStats.received),
+		"reports_dropped":  atomic.LoadInt64(&reportStats.dropped),
+		"reports_deduped":  atomic.LoadInt64(&reportStats.deduped),
+	}
+}
+
+func dispatch(e *Error) {
+	atomic.AddInt64(&reportStats.received, 1)
+	if pipeline == nil {
+		return
+	}
+	pipeline.Report(e)
+}
+
+// fingerprint identifies the failure e represents for the purposes of
+// deduplication and sampling: same error_type, error_op, error_category and
+// error_location is treated as the same recurring failure.
+func fingerprint(e *Error) string {
+	loc, _ := e.data["error_location"].(string)
+	return fmt.Sprintf("%v|%v|%v|%v", e.data["error_type"], e.data["error_op"], e.data["error_category"], loc)
+}
+
+type dedupEntry struct {
+	representative *Error
+	count          int
+	firstSeen      time.Time
+	lastSeen       time.Time
+}
+
+// NewDeduplicatingMiddleware groups Errors sharing the same fingerprint (see
+// fingerprint) into window-long buckets, reporting a single representative
+// per bucket annotated with duplicate_count, first_seen and last_seen
+// instead of every occurrence. Use this for errors, like repeated dial
+// failures while offline, that can otherwise fire thousands of times a
+// minute.
+func NewDeduplicatingMiddleware(window time.Duration) ReporterMiddleware {
+	return func(next ErrorReporter) ErrorReporter {
+		var mu sync.Mutex
+		buckets := make(map[string]*dedupEntry)
+
+		flush := func() {
+			mu.Lock()
+			due := buckets
+			buckets = make(map[string]*dedupEntry)
+			mu.Unlock()
+			for _, entry := range due {
+				entry.representative.data["duplicate_count"] = entry.count
+				entry.representative.data["first_seen"] = entry.firstSeen.Format(time.RFC3339)
+				entry.representative.data["last_seen"] = entry.lastSeen.Format(time.RFC3339)
+				next.Report(entry.representative)
+			}
+		}
+		go func() {
+			ticker := time.NewTicker(window)
+			defer ticker.Stop()
+			for range ticker.C {
+				flush()
+			}
+		}()
+
+		return reporterFunc(func(e *Error) {
+			key := fingerprint(e)
+			now := time.Now()
+			mu.Lock()
+			entry, ok := buckets[key]
+			if !ok {
+				buckets[key] = &dedupEntry{representative: e, count: 1, firstSeen: now, lastSeen: now}
+				mu.Unlock()
+				return
+			}
+			entry.count++
+			entry.lastSeen = now
+			mu.Unlock()
+			atomic.AddInt64(&reportStats.deduped, 1)
+		})
+	}
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// NewSamplingMiddleware reports at most ratePerFingerprint Errors per second
+// for each distinct fingerprint (see fingerprint), using an independent
+// token bucket per fingerprint. Anything beyond that rate is dropped and
+// counted in Stats.
+func NewSamplingMiddleware(ratePerFingerprint float64) ReporterMiddleware {
+	return func(next ErrorReporter) ErrorReporter {
+		var mu sync.Mutex
+		buckets := make(map[string]*tokenBucket)
+		return reporterFunc(func(e *Error) {
+			key := fingerprint(e)
+			now := time.Now()
+			mu.Lock()
+			b, ok := buckets[key]
+			if !ok {
+				b = &tokenBucket{tokens: ratePerFingerprint, lastRefill: now}
+				buckets[key] = b
+			} else {
+				elapsed := now.Sub(b.lastRefill).Seconds()
+				b.tokens += elapsed * ratePerFingerprint
+				if b.tokens > ratePerFingerprint {
+					b.tokens = ratePerFingerprint
+				}
+				b.lastRefill = now
+			}
+			allow := b.tokens >= 1
+			if allow {
+				b.tokens--
+			}
+			mu.Unlock()
+			if !allow {
+				atomic.AddInt64(&reportStats.dropped, 1)
+				return
+			}
+			next.Report(e)
+		})
+	}
+}
+
+// NewBatchingMiddleware buffers reported Errors in a bounded ring of
+// capacity entries and flushes them to next every flushInterval, or
+// immediately once the ring fills up, whichever comes first. Report never
+// blocks the caller: if the ring is already full when a new Error arrives,
+// the oldest buffered entry is dropped (and counted in Stats) to make room.
+func NewBatchingMiddleware(capacity int, flushInterval time.Duration) ReporterMiddleware {
+	return func(next ErrorReporter) ErrorReporter {
+		var mu sync.Mutex
+		buf := make([]*Error, 0, capacity)
+
+		flush := func() {
+			mu.Lock()
+			batch := buf
+			buf = make([]*Error, 0, capacity)
+			mu.Unlock()
+			for _, e := range batch {
+				next.Report(e)
+			}
+		}
+		go func() {
+			ticker := time.NewTicker(flushInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				flush()
+			}
+		}()
+
+		return reporterFunc(func(e *Error) {
+			mu.Lock()
+			dropped := len(buf) >= capacity
+			if dropped {
+				buf = buf[1:]
+			}
+			buf = append(buf, e)
+			full := len(buf) >= capacity
+			mu.Unlock()
+			if dropped {
+				atomic.AddInt64(&reportStats.dropped, 1)
+			}
+			if full {
+				// Report must never block the caller, so the size-triggered
+				// flush runs on its own goroutine rather than inline here,
+				// same as the ticker-triggered one above.
+				go flush()
+			}
+		})
+	}
+}
+
+// NewSeverityFilterMiddleware drops Errors whose Severity() is below min,
+// counting each drop in Stats.
+func NewSeverityFilterMiddleware(min Severity) ReporterMiddleware {
+	return func(next ErrorReporter) ErrorReporter {
+		return reporterFunc(func(e *Error) {
+			if e.Severity() < min {
+				atomic.AddInt64(&reportStats.dropped, 1)
+				return
+			}
+			next.Report(e)
+		})
+	}
+}
+
 // New creates an Error with supplied description
 func New(desc string) (e *Error) {
 	e = buildError(desc, nil)
@@ -91,7 +435,8 @@ func wrapSkipFrames(err error, skip int) *Error {
 // reporting and logging. It's not meant to be created directly. User New(),
 // Wrap() and Report() instead.
 type Error struct {
-	data context.Map
+	data   context.Map
+	source error
 }
 
 // Fill implements the method from the context.Contextual interface.
@@ -134,24 +479,289 @@ func (e *Error) Error() string {
 	return e.data["error"].(string)
 }
 
+// Unwrap returns the error that was originally passed to Wrap(), if any,
+// allowing this Error to participate in errors.Is/errors.As/errors.Unwrap
+// chains from the standard library.
+func (e *Error) Unwrap() error {
+	return e.source
+}
+
+// Is reports whether this Error, or any error in the chain it wraps,
+// matches target. It lets callers use errors.Is(err, context.Canceled)
+// against an Error the same way they would against the original error.
+func (e *Error) Is(target error) bool {
+	return errors.Is(e.source, target)
+}
+
+// As finds the first error in the chain this Error wraps that matches
+// target, and if found, sets target to that error value and returns true,
+// exactly like errors.As would against the originally wrapped error.
+func (e *Error) As(target interface{}) bool {
+	return errors.As(e.source, target)
+}
+
+// IsTimeout indicates that the underlying error, or something it wraps,
+// reported itself as a timeout (for example a net.Error whose Timeout()
+// returns true, or a context.DeadlineExceeded in the chain).
+func (e *Error) IsTimeout() bool {
+	v, _ := e.data["timeout"].(bool)
+	return v
+}
+
+// IsTemporary indicates that the underlying error, or something it wraps,
+// reported itself as temporary via net.Error's Temporary().
+func (e *Error) IsTemporary() bool {
+	v, _ := e.data["temporary"].(bool)
+	return v
+}
+
+// IsCanceled indicates that the underlying error is or wraps
+// context.Canceled, i.e. the operation was called off by the caller rather
+// than failing on its own.
+func (e *Error) IsCanceled() bool {
+	v, _ := e.data["canceled"].(bool)
+	return v
+}
+
+// IsDNSNotFound indicates that the underlying error is a *net.DNSError
+// whose IsNotFound field is set, i.e. the name genuinely doesn't resolve
+// rather than the lookup having failed for some other reason.
+func (e *Error) IsDNSNotFound() bool {
+	v, _ := e.data["dns_not_found"].(bool)
+	return v
+}
+
+// Category returns the coarse-grained error_category this Error was
+// classified into at wrap time (one of "dns", "dial", "tls_handshake",
+// "tls_verify", "http_protocol", "context", "filesystem", "syscall" or
+// "application").
+func (e *Error) Category() string {
+	v, _ := e.data["error_category"].(string)
+	return v
+}
+
+// Severity is how serious an Error is, for the purposes of
+// NewSeverityFilterMiddleware. buildError assigns a default based on the
+// error's category; use WithSeverity to override it.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarn
+	SeverityError
+	SeverityCritical
+)
+
+// WithSeverity overrides the default severity assigned to this Error.
+func (e *Error) WithSeverity(s Severity) *Error {
+	e.data["severity"] = s
+	return e
+}
+
+// Severity returns the severity assigned to this Error, defaulting to
+// SeverityError if none was ever set.
+func (e *Error) Severity() Severity {
+	if s, ok := e.data["severity"].(Severity); ok {
+		return s
+	}
+	return SeverityError
+}
+
+// jsonError is the stable wire schema for Error's JSON representation:
+// well-known fields at the top level, everything else folded into extras.
+type jsonError struct {
+	Error         string                 `json:"error"`
+	ErrorType     string                 `json:"error_type,omitempty"`
+	ErrorOp       string                 `json:"error_op,omitempty"`
+	ErrorCategory string                 `json:"error_category,omitempty"`
+	Timeout       bool                   `json:"timeout,omitempty"`
+	Severity      Severity               `json:"severity"`
+	Stack         []context.Map          `json:"stack,omitempty"`
+	Extras        map[string]interface{} `json:"extras,omitempty"`
+}
+
+// jsonTopLevelKeys are the data keys that get their own field in jsonError
+// rather than being folded into extras. Severity is here too: left to fall
+// through to Extras, it would round-trip through encoding/json as a plain
+// float64 instead of a Severity, breaking the Severity()/WithSeverity()
+// type assertion on the way back in via NewFromJSON.
+var jsonTopLevelKeys = map[string]bool{
+	"error":          true,
+	"error_type":     true,
+	"error_op":       true,
+	"error_category": true,
+	"timeout":        true,
+	"severity":       true,
+	"stack":          true,
+}
+
+// MarshalJSON implements json.Marshaler, emitting the stable schema defined
+// by jsonError: well-known fields at the top level, with everything else —
+// fields parseError pulled out of the wrapped error's chain, as well as
+// anything attached via With() — folded into extras. This is what lets an
+// Error ship cleanly to a structured log backend (Elasticsearch, Loki, an
+// OTLP collector) instead of collapsing to a single string.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	je := jsonError{
+		Error:  e.Error(),
+		Extras: make(map[string]interface{}),
+	}
+	if v, ok := e.data["error_type"].(string); ok {
+		je.ErrorType = v
+	}
+	if v, ok := e.data["error_op"].(string); ok {
+		je.ErrorOp = v
+	}
+	if v, ok := e.data["error_category"].(string); ok {
+		je.ErrorCategory = v
+	}
+	if v, ok := e.data["timeout"].(bool); ok {
+		je.Timeout = v
+	}
+	je.Severity = e.Severity()
+	if v, ok := e.data["stack"].([]context.Map); ok {
+		je.Stack = v
+	}
+	for key, value := range e.data {
+		if jsonTopLevelKeys[key] {
+			continue
+		}
+		je.Extras[key] = value
+	}
+	return json.Marshal(je)
+}
+
+// NewFromJSON reconstitutes an Error previously serialized with
+// MarshalJSON, restoring its well-known fields and extras so that context
+// captured in one process (e.g. a helper binary) survives crossing into
+// another (e.g. the main process) rather than collapsing into a plain
+// string.
+func NewFromJSON(b []byte) (*Error, error) {
+	var je jsonError
+	if err := json.Unmarshal(b, &je); err != nil {
+		return nil, err
+	}
+	e := &Error{data: context.AsMap(nil, false)}
+	e.data["error"] = je.Error
+	e.data["error_type"] = je.ErrorType
+	e.data["error_op"] = je.ErrorOp
+	e.data["error_category"] = je.ErrorCategory
+	e.data["timeout"] = je.Timeout
+	e.data["severity"] = je.Severity
+	if je.Stack != nil {
+		e.data["stack"] = je.Stack
+	}
+	for key, value := range je.Extras {
+		e.data[key] = value
+	}
+	return e, nil
+}
+
+// ToOTLP produces a map shaped like an OTLP LogRecord: severity_text derived
+// from error_category, body set to the error message, and every other field
+// (well-known or extra) folded into attributes.
+func (e *Error) ToOTLP() map[string]interface{} {
+	attributes := make(map[string]interface{}, len(e.data))
+	for key, value := range e.data {
+		if key == "error" {
+			continue
+		}
+		attributes[key] = value
+	}
+	return map[string]interface{}{
+		"severity_text": otlpSeverity(e.Category()),
+		"body":          e.Error(),
+		"attributes":    attributes,
+	}
+}
+
+// otlpSeverity maps an error_category to an OTLP severity_text level.
+func otlpSeverity(category string) string {
+	switch category {
+	case "context":
+		return "INFO"
+	case "application":
+		return "ERROR"
+	default:
+		return "WARN"
+	}
+}
+
+// attachStack captures the current goroutine's call stack onto e, skipping
+// the requested number of frames plus this package's own frames. It records
+// a compact error_location (the innermost frame outside of this package and
+// the runtime) and a full stack of {func, file, line, pc} entries, and uses
+// the innermost frame's package to drive the golog.LoggerFor call in
+// Report().
 func (e *Error) attachStack(skip int) {
-	// TODO: reenable this
-	// caller := stack.Caller(skip)
-	// e.data["p"]
-	// e.Package = fmt.Sprintf("%+k", caller)
-	// e.Func = fmt.Sprintf("%n", caller)
-	// e.FileLine = fmt.Sprintf("%+v", caller)
-	// e.Stack = stack.Trace().TrimBelow(caller).TrimRuntime()
+	if !cfg.captureStack {
+		return
+	}
+
+	pcs := make([]uintptr, cfg.maxStackFrames)
+	n := runtime.Callers(skip+1, pcs)
+	if n == 0 {
+		return
+	}
+
+	frames := runtime.CallersFrames(pcs[:n])
+	stack := make([]context.Map, 0, n)
+	locationFound := false
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, context.Map{
+			"func": frame.Function,
+			"file": frame.File,
+			"line": frame.Line,
+			"pc":   frame.PC,
+		})
+		if !locationFound && !isInternalFrame(frame.Function) {
+			e.data["error_location"] = fmt.Sprintf("%s (%s:%d)", frame.Function, filepath.Base(frame.File), frame.Line)
+			e.data["error_package"] = packageFromFunc(frame.Function)
+			locationFound = true
+		}
+		if !more {
+			break
+		}
+	}
+	e.data["stack"] = stack
+}
+
+// isInternalFrame reports whether function belongs to the Go runtime or to
+// this package itself, so attachStack can find the first frame that
+// actually belongs to the caller even if skip undercounts.
+func isInternalFrame(function string) bool {
+	return strings.HasPrefix(function, "runtime.") ||
+		strings.Contains(function, "github.com/getlantern/errors.")
+}
+
+// packageFromFunc extracts the import path of the package that function
+// (as reported by a runtime.Frame, e.g.
+// "github.com/getlantern/foo.Bar" or "github.com/getlantern/foo.(*T).Bar")
+// belongs to.
+func packageFromFunc(function string) string {
+	lastSlash := strings.LastIndex(function, "/")
+	rest := function[lastSlash+1:]
+	if dot := strings.Index(rest, "."); dot >= 0 {
+		return function[:lastSlash+1+dot]
+	}
+	return function
 }
 
 func buildError(desc string, source error) *Error {
 	e := &Error{
 		// We initialize the data using the current context, which allows the error
 		// to propagate contextual information to higher layers.
-		data: context.AsMap(nil, false),
+		data:   context.AsMap(nil, false),
+		source: source,
 	}
 
 	errorType := "errors.Error"
+	category := "application"
+	e.data["timeout"] = false
+	e.data["temporary"] = false
+	e.data["canceled"] = false
+	e.data["dns_not_found"] = false
 	if source != nil {
 		op, goType, sourceDesc, extra := parseError(source)
 		if desc == "" && source != nil {
@@ -164,30 +774,138 @@ func buildError(desc string, source error) *Error {
 				e.data[key] = value
 			}
 		}
+		timeout, temporary, canceled, dnsNotFound, cat := classifyError(source, op, goType)
+		e.data["timeout"] = timeout
+		e.data["temporary"] = temporary
+		e.data["canceled"] = canceled
+		e.data["dns_not_found"] = dnsNotFound
+		category = cat
 	}
 	e.data["error"] = desc
 	e.data["error_type"] = errorType
+	e.data["error_category"] = category
+	if category == "context" {
+		e.data["severity"] = SeverityInfo
+	} else {
+		e.data["severity"] = SeverityError
+	}
 
 	return e
 }
 
+// classifyError computes the boolean traits and coarse-grained category
+// used by Error's IsTimeout/IsTemporary/IsCanceled/IsDNSNotFound/Category
+// accessors. It's computed once here at wrap time rather than on every
+// accessor call, since it has to walk the whole error chain.
+func classifyError(err error, op string, goType string) (timeout, temporary, canceled, dnsNotFound bool, category string) {
+	canceled = errors.Is(err, stdcontext.Canceled)
+	timeout = errors.Is(err, stdcontext.DeadlineExceeded)
+
+	for layer := err; layer != nil; layer = errors.Unwrap(layer) {
+		if te, ok := layer.(interface{ Timeout() bool }); ok && te.Timeout() {
+			timeout = true
+		}
+		if te, ok := layer.(interface{ Temporary() bool }); ok && te.Temporary() {
+			temporary = true
+		}
+		if dnsErr, ok := layer.(*net.DNSError); ok && dnsErr.IsNotFound {
+			dnsNotFound = true
+		}
+	}
+
+	category = categorizeError(op, goType, canceled)
+	return
+}
+
+// categorizeError maps the goType/op recorded for an error to one of the
+// error_category buckets downstream dashboards group on.
+func categorizeError(op, goType string, canceled bool) string {
+	switch {
+	case canceled:
+		return "context"
+	case goType == "net.DNSError":
+		return "dns"
+	case strings.HasPrefix(goType, "tls."):
+		return "tls_handshake"
+	case strings.HasPrefix(goType, "x509."):
+		return "tls_verify"
+	case strings.HasPrefix(goType, "http.") || strings.HasPrefix(goType, "textproto."):
+		return "http_protocol"
+	case goType == "os.PathError" || goType == "os.LinkError":
+		return "filesystem"
+	case goType == "os.SyscallError":
+		return "syscall"
+	case goType == "syscall.Errno" && op != "":
+		// A syscall.Errno recorded as the goType together with an op can
+		// only have come in wrapped by something that supplies an op but
+		// doesn't claim a goType of its own — in this package, that's
+		// *net.OpError. So a syscall.Errno
+		// with an op (e.g. a dial attempt's ECONNREFUSED or ETIMEDOUT) is a
+		// network failure, not a filesystem one, and should be classified
+		// "dial" rather than falling into the generic "syscall" bucket
+		// below, which is reserved for a bare syscall.Errno with no
+		// network op around it.
+		return "dial"
+	case goType == "syscall.Errno":
+		return "syscall"
+	case op != "":
+		return "dial"
+	default:
+		return "application"
+	}
+}
+
+// parseError walks the full chain of err (err itself, then whatever it
+// wraps, following errors.Unwrap as far as it goes) and merges what it
+// finds into a single (op, goType, desc, extra) result. The first layer to
+// produce a goType/desc wins those fields (this is usually the outermost
+// layer with type information of its own, e.g. a *net.DNSError found
+// beneath a *net.OpError); op and extra are merged from every layer, with
+// the outermost non-empty value for a given key taking precedence, so that
+// e.g. a *net.OpError's "op" and "network" survive alongside a nested
+// *net.DNSError's "domain" and a syscall.Errno's "errno" further down.
 func parseError(err error) (op string, goType string, desc string, extra map[string]string) {
 	extra = make(map[string]string)
 
+	for layer := err; layer != nil; layer = errors.Unwrap(layer) {
+		layerOp, layerType, layerDesc, layerExtra := parseErrorLayer(layer)
+		for key, value := range layerExtra {
+			if extra[key] == "" {
+				extra[key] = value
+			}
+		}
+		if goType == "" && layerType != "" {
+			goType = layerType
+			desc = layerDesc
+		}
+		if op == "" && layerOp != "" {
+			op = layerOp
+		}
+	}
+	return
+}
+
+// parseErrorLayer extracts whatever it can from a single error value,
+// without following anything it wraps (parseError takes care of walking the
+// chain). Wrapper types like *net.OpError contribute op/extra but no
+// goType/desc of their own, since the wrapped error underneath them (found
+// on the next call, via errors.Unwrap) describes the failure more
+// specifically.
+func parseErrorLayer(err error) (op string, goType string, desc string, extra map[string]string) {
+	extra = make(map[string]string)
+
 	// interfaces
 	if _, ok := err.(net.Error); ok {
-		if opError, ok := err.(*net.OpError); ok {
-			op = opError.Op
-			if opError.Source != nil {
-				extra["local_addr"] = opError.Source.String()
+		switch actual := err.(type) {
+		case *net.OpError:
+			op = actual.Op
+			if actual.Source != nil {
+				extra["local_addr"] = actual.Source.String()
 			}
-			if opError.Addr != nil {
-				extra["remote_addr"] = opError.Addr.String()
+			if actual.Addr != nil {
+				extra["remote_addr"] = actual.Addr.String()
 			}
-			extra["network"] = opError.Net
-			err = opError.Err
-		}
-		switch actual := err.(type) {
+			extra["network"] = actual.Net
 		case *net.AddrError:
 			goType = "net.AddrError"
 			desc = actual.Err
@@ -212,6 +930,7 @@ func parseError(err error) (op string, goType string, desc string, extra map[str
 		case syscall.Errno:
 			goType = "syscall.Errno"
 			desc = actual.Error()
+			extra["errno"] = strconv.Itoa(int(actual))
 		case *url.Error:
 			goType = "url.Error"
 			desc = actual.Err.Error()
@@ -232,7 +951,6 @@ func parseError(err error) (op string, goType string, desc string, extra map[str
 		}
 		return
 	}
-
 	// structs
 	switch actual := err.(type) {
 	case *http.ProtocolError:
@@ -0,0 +1,383 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type slowReporter struct {
+	delay   time.Duration
+	reports int32
+}
+
+func (r *slowReporter) Report(e *Error) {
+	time.Sleep(r.delay)
+	atomic.AddInt32(&r.reports, 1)
+}
+
+type countingReporter struct {
+	count int32
+}
+
+func (r *countingReporter) Report(e *Error) {
+	atomic.AddInt32(&r.count, 1)
+}
+
+// recordingReporter records every Error it receives, in order. It's used to
+// inspect exactly what a middleware let through (and how it annotated it),
+// rather than just counting reports.
+type recordingReporter struct {
+	mu     sync.Mutex
+	errors []*Error
+}
+
+func (r *recordingReporter) Report(e *Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, e)
+}
+
+func (r *recordingReporter) reported() []*Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*Error, len(r.errors))
+	copy(out, r.errors)
+	return out
+}
+
+// TestRegisterReporterMiddlewareDoesNotLeakGoroutines guards against
+// RegisterReporterMiddleware rebuilding the whole pipeline (and thus
+// re-invoking every previously registered middleware factory) on each call.
+// Stateful middlewares like NewDeduplicatingMiddleware and
+// NewBatchingMiddleware each start exactly one background goroutine; that
+// count shouldn't grow every time another middleware is registered.
+func TestRegisterReporterMiddlewareDoesNotLeakGoroutines(t *testing.T) {
+	Initialize("test", &countingReporter{}, false)
+
+	runtime.GC()
+	before := runtime.NumGoroutine()
+
+	RegisterReporterMiddleware(NewDeduplicatingMiddleware(time.Hour))
+	RegisterReporterMiddleware(NewSamplingMiddleware(100))
+	RegisterReporterMiddleware(NewBatchingMiddleware(10, time.Hour))
+	RegisterReporterMiddleware(NewSeverityFilterMiddleware(SeverityInfo))
+	// Registering one more middleware should only ever start its own
+	// goroutines (none, in this case), not re-run the factories above.
+	RegisterReporterMiddleware(NewSeverityFilterMiddleware(SeverityInfo))
+
+	runtime.Gosched()
+	time.Sleep(10 * time.Millisecond)
+	after := runtime.NumGoroutine()
+
+	if got := after - before; got > 2 {
+		t.Fatalf("expected at most 2 new goroutines (one each for dedup and batching), got %d (before=%d after=%d)", got, before, after)
+	}
+}
+
+// TestRegisterReporterMiddlewareOrder documents that middlewares wrap
+// incrementally: the most recently registered one is outermost and so sees
+// a reported Error first.
+func TestRegisterReporterMiddlewareOrder(t *testing.T) {
+	Initialize("test", &countingReporter{}, false)
+
+	var order []string
+	RegisterReporterMiddleware(func(next ErrorReporter) ErrorReporter {
+		return reporterFunc(func(e *Error) {
+			order = append(order, "first")
+			next.Report(e)
+		})
+	})
+	RegisterReporterMiddleware(func(next ErrorReporter) ErrorReporter {
+		return reporterFunc(func(e *Error) {
+			order = append(order, "second")
+			next.Report(e)
+		})
+	})
+
+	pipeline.Report(New("boom"))
+
+	if len(order) != 2 || order[0] != "second" || order[1] != "first" {
+		t.Fatalf("expected most-recently-registered middleware to run first, got %v", order)
+	}
+}
+
+// TestBatchingMiddlewareReportDoesNotBlock checks that filling the ring
+// (triggering a size-based flush) returns to the caller immediately instead
+// of waiting for every buffered report to be delivered to a slow next
+// reporter.
+func TestBatchingMiddlewareReportDoesNotBlock(t *testing.T) {
+	reporter := &slowReporter{delay: 100 * time.Millisecond}
+	Initialize("test", reporter, false)
+	RegisterReporterMiddleware(NewBatchingMiddleware(4, time.Hour))
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		pipeline.Report(New("boom"))
+	}
+	elapsed := time.Since(start)
+
+	if elapsed > 50*time.Millisecond {
+		t.Fatalf("Report blocked for %s; filling the ring should not wait on the slow reporter", elapsed)
+	}
+}
+
+// TestDeduplicatingMiddlewareAnnotatesAndSuppresses checks that reports
+// sharing a fingerprint within the same window collapse into a single
+// representative annotated with duplicate_count/first_seen/last_seen, with
+// the suppressed duplicates counted in Stats rather than forwarded to next.
+func TestDeduplicatingMiddlewareAnnotatesAndSuppresses(t *testing.T) {
+	reporter := &recordingReporter{}
+	Initialize("test", reporter, false)
+	RegisterReporterMiddleware(NewDeduplicatingMiddleware(20 * time.Millisecond))
+
+	before := Stats()["reports_deduped"]
+
+	for i := 0; i < 3; i++ {
+		pipeline.Report(New("boom"))
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	got := reporter.reported()
+	if len(got) != 1 {
+		t.Fatalf("expected exactly 1 representative to reach the underlying reporter, got %d", len(got))
+	}
+	if got[0].data["duplicate_count"] != 3 {
+		t.Errorf("expected duplicate_count 3, got %v", got[0].data["duplicate_count"])
+	}
+	if _, ok := got[0].data["first_seen"]; !ok {
+		t.Errorf("expected first_seen to be set on the representative")
+	}
+	if _, ok := got[0].data["last_seen"]; !ok {
+		t.Errorf("expected last_seen to be set on the representative")
+	}
+	if diff := Stats()["reports_deduped"] - before; diff != 2 {
+		t.Errorf("expected 2 suppressed duplicates counted in Stats, got %d", diff)
+	}
+}
+
+// TestSamplingMiddlewareCapsRate checks that NewSamplingMiddleware only lets
+// ratePerFingerprint reports through per fingerprint, dropping (and counting
+// in Stats) the rest.
+func TestSamplingMiddlewareCapsRate(t *testing.T) {
+	reporter := &recordingReporter{}
+	Initialize("test", reporter, false)
+	RegisterReporterMiddleware(NewSamplingMiddleware(2))
+
+	before := Stats()["reports_dropped"]
+
+	for i := 0; i < 10; i++ {
+		pipeline.Report(New("boom"))
+	}
+
+	if got := len(reporter.reported()); got != 2 {
+		t.Fatalf("expected only 2 of 10 rapid-fire reports to pass a rate of 2/fingerprint, got %d", got)
+	}
+	if diff := Stats()["reports_dropped"] - before; diff != 8 {
+		t.Errorf("expected 8 reports dropped by the sampler, got %d", diff)
+	}
+}
+
+// TestSeverityFilterMiddlewareDropsBelowMinimum checks that
+// NewSeverityFilterMiddleware forwards only Errors at or above min,
+// dropping (and counting in Stats) the rest.
+func TestSeverityFilterMiddlewareDropsBelowMinimum(t *testing.T) {
+	reporter := &recordingReporter{}
+	Initialize("test", reporter, false)
+	RegisterReporterMiddleware(NewSeverityFilterMiddleware(SeverityWarn))
+
+	before := Stats()["reports_dropped"]
+
+	pipeline.Report(New("boom").WithSeverity(SeverityInfo))
+	pipeline.Report(New("boom").WithSeverity(SeverityWarn))
+	pipeline.Report(New("boom").WithSeverity(SeverityCritical))
+
+	if got := len(reporter.reported()); got != 2 {
+		t.Fatalf("expected the two at-or-above-minimum reports to pass through, got %d", got)
+	}
+	if diff := Stats()["reports_dropped"] - before; diff != 1 {
+		t.Errorf("expected 1 below-minimum report dropped, got %d", diff)
+	}
+}
+
+// TestStatsReflectsReceivedAndDropped checks that Stats' reports_received
+// and reports_dropped counters track actual dispatch()/middleware activity,
+// using Error.Report() (the real entry point) rather than calling the
+// pipeline directly.
+func TestStatsReflectsReceivedAndDropped(t *testing.T) {
+	reporter := &recordingReporter{}
+	Initialize("test", reporter, false)
+	RegisterReporterMiddleware(NewSeverityFilterMiddleware(SeverityError))
+
+	before := Stats()
+
+	New("boom").WithSeverity(SeverityInfo).Report()
+	New("boom").WithSeverity(SeverityCritical).Report()
+
+	after := Stats()
+	if diff := after["reports_received"] - before["reports_received"]; diff != 2 {
+		t.Errorf("expected reports_received to grow by 2, got %d", diff)
+	}
+	if diff := after["reports_dropped"] - before["reports_dropped"]; diff != 1 {
+		t.Errorf("expected reports_dropped to grow by 1, got %d", diff)
+	}
+}
+
+// TestJSONRoundTripPreservesSeverity guards against Severity falling into
+// the untyped Extras map, where a JSON round trip would decode it back as
+// a float64 and silently reset Severity() to SeverityError.
+func TestJSONRoundTripPreservesSeverity(t *testing.T) {
+	original := New("x").WithSeverity(SeverityCritical)
+
+	b, err := original.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	restored, err := NewFromJSON(b)
+	if err != nil {
+		t.Fatalf("NewFromJSON: %v", err)
+	}
+
+	if got := restored.Severity(); got != SeverityCritical {
+		t.Fatalf("expected severity to survive the JSON round trip as SeverityCritical, got %v", got)
+	}
+}
+
+// TestWrapWalksChain checks that Wrap pulls fields from every layer of a
+// wrapped error, not just the outermost one, and that Is/As delegate to the
+// wrapped chain the same way the standard errors package would.
+func TestWrapWalksChain(t *testing.T) {
+	source := &net.OpError{
+		Op:   "dial",
+		Net:  "tcp",
+		Addr: &net.IPAddr{IP: net.ParseIP("127.0.0.1")},
+		Err:  syscall.ECONNREFUSED,
+	}
+
+	e := Wrap(source)
+
+	if e.data["error_op"] != "dial" {
+		t.Errorf("expected error_op %q from the outer *net.OpError, got %v", "dial", e.data["error_op"])
+	}
+	if e.data["network"] != "tcp" {
+		t.Errorf("expected network %q from the outer *net.OpError, got %v", "tcp", e.data["network"])
+	}
+	if e.data["errno"] == "" || e.data["errno"] == nil {
+		t.Errorf("expected errno to be pulled from the wrapped syscall.Errno, got %v", e.data["errno"])
+	}
+	if e.Category() != "dial" {
+		t.Errorf("expected category %q for a dial *net.OpError wrapping a syscall.Errno, got %q", "dial", e.Category())
+	}
+
+	if !e.Is(syscall.ECONNREFUSED) {
+		t.Errorf("expected Is(syscall.ECONNREFUSED) to be true for a wrapped *net.OpError around it")
+	}
+	var opErr *net.OpError
+	if !e.As(&opErr) {
+		t.Errorf("expected As(*net.OpError) to succeed")
+	}
+}
+
+// TestWrapCategorizesCanceled checks that an error wrapping
+// context.Canceled is classified into the "context" category and flagged
+// as canceled, even though the wrapping error itself is just a plain
+// fmt.Errorf("%w", ...).
+func TestWrapCategorizesCanceled(t *testing.T) {
+	e := Wrap(fmt.Errorf("giving up: %w", context.Canceled))
+
+	if !e.IsCanceled() {
+		t.Errorf("expected IsCanceled() to be true")
+	}
+	if e.Category() != "context" {
+		t.Errorf("expected category %q, got %q", "context", e.Category())
+	}
+	if !e.Is(context.Canceled) {
+		t.Errorf("expected Is(context.Canceled) to be true")
+	}
+}
+
+// TestCategorizeDNSError checks that a *net.DNSError is classified into the
+// "dns" category and that its IsNotFound field surfaces via IsDNSNotFound().
+func TestCategorizeDNSError(t *testing.T) {
+	e := Wrap(&net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true})
+
+	if e.Category() != "dns" {
+		t.Errorf("expected category %q, got %q", "dns", e.Category())
+	}
+	if !e.IsDNSNotFound() {
+		t.Errorf("expected IsDNSNotFound() to be true")
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return false }
+
+// TestCategorizeTimeout checks that IsTimeout() reflects a Timeout() true
+// found anywhere in the wrapped chain, not just on the outermost error.
+func TestCategorizeTimeout(t *testing.T) {
+	e := Wrap(&net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}})
+
+	if !e.IsTimeout() {
+		t.Errorf("expected IsTimeout() to be true")
+	}
+	if e.IsTemporary() {
+		t.Errorf("expected IsTemporary() to be false")
+	}
+}
+
+// TestStackCaptureDefaultOn checks that New() captures a stack and an
+// error_location by default.
+func TestStackCaptureDefaultOn(t *testing.T) {
+	Initialize("test", &countingReporter{}, false)
+
+	e := New("boom")
+
+	if _, ok := e.data["stack"]; !ok {
+		t.Errorf("expected a stack to be captured by default")
+	}
+	loc, _ := e.data["error_location"].(string)
+	if loc == "" {
+		t.Errorf("expected error_location to be set by default")
+	}
+}
+
+// TestStackCaptureCanBeDisabled checks the DisableStackCapture Initialize
+// option actually turns off stack capture, for performance-sensitive paths.
+func TestStackCaptureCanBeDisabled(t *testing.T) {
+	Initialize("test", &countingReporter{}, false, DisableStackCapture())
+	defer Initialize("test", &countingReporter{}, false)
+
+	e := New("boom")
+
+	if _, ok := e.data["stack"]; ok {
+		t.Errorf("expected stack capture to be disabled")
+	}
+}
+
+// TestMaxStackFramesCapsFrameCount checks that MaxStackFrames actually
+// bounds how many frames attachStack records.
+func TestMaxStackFramesCapsFrameCount(t *testing.T) {
+	Initialize("test", &countingReporter{}, false, MaxStackFrames(2))
+	defer Initialize("test", &countingReporter{}, false)
+
+	e := New("boom")
+
+	stack, ok := e.data["stack"]
+	if !ok {
+		t.Fatal("expected a stack to be captured")
+	}
+	if n := reflect.ValueOf(stack).Len(); n > 2 {
+		t.Errorf("expected at most 2 frames, got %d", n)
+	}
+}